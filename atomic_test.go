@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testReporter discards every event; used where tests only care about the
+// side effects of the function under test, not what it reports.
+type testReporter struct{}
+
+func (testReporter) Backup(src, dest string)                         {}
+func (testReporter) Warning(format string, args ...interface{})      {}
+func (testReporter) Line(status, line string)                        {}
+func (testReporter) Summary(stats Stats, cfg *Config, target string) {}
+
+func TestWriteAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.txt")
+
+	if err := os.WriteFile(target, []byte("existing\n"), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+
+	if err := writeAtomicFile(target, []byte("existing\n"), []byte("new\n"), testReporter{}); err != nil {
+		t.Fatalf("writeAtomicFile: %v", err)
+	}
+
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if want := "existing\nnew\n"; string(got) != want {
+		t.Fatalf("target content = %q, want %q", got, want)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the target file to remain, found %d entries", len(entries))
+	}
+}
+
+// TestWriteAtomicFileRenameFailureLeavesTargetUntouched forces the final
+// os.Rename in writeAtomicFile to fail (by making the target a non-empty
+// directory, which os.Rename refuses to replace with a file) and asserts
+// the original target survives completely unchanged - i.e. a failure that
+// strikes after the temp file is fully written and synced still can't
+// clobber the original.
+func TestWriteAtomicFileRenameFailureLeavesTargetUntouched(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.txt")
+
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatalf("Mkdir target: %v", err)
+	}
+	marker := filepath.Join(target, "marker.txt")
+	if err := os.WriteFile(marker, []byte("keep me\n"), 0644); err != nil {
+		t.Fatalf("seed marker file: %v", err)
+	}
+
+	err := writeAtomicFile(target, []byte("existing\n"), []byte("new\n"), testReporter{})
+	if err == nil {
+		t.Fatal("writeAtomicFile succeeded, want an error from the rename onto a non-empty directory")
+	}
+
+	info, statErr := os.Stat(target)
+	if statErr != nil {
+		t.Fatalf("target missing after failed writeAtomicFile: %v", statErr)
+	}
+	if !info.IsDir() {
+		t.Fatalf("target was replaced by the temp file despite the rename failing")
+	}
+	got, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("ReadFile marker: %v", err)
+	}
+	if string(got) != "keep me\n" {
+		t.Fatalf("marker content = %q, want %q", got, "keep me\n")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != "out.txt" {
+			t.Errorf("leftover temp file %q not cleaned up", e.Name())
+		}
+	}
+}
+
+func TestIsAtomicUnsafeTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	regular := filepath.Join(dir, "regular.txt")
+	if err := os.WriteFile(regular, []byte("x"), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if isAtomicUnsafeTarget(regular) {
+		t.Errorf("regular file reported as unsafe")
+	}
+
+	missing := filepath.Join(dir, "missing.txt")
+	if isAtomicUnsafeTarget(missing) {
+		t.Errorf("non-existent path reported as unsafe")
+	}
+
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(regular, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if !isAtomicUnsafeTarget(link) {
+		t.Errorf("symlink not reported as unsafe")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import "testing"
+
+func TestMemoryDedupSet(t *testing.T) {
+	d := newMemoryDedupSet()
+	if d.SeenOrAdd("foo") {
+		t.Fatal("first SeenOrAdd(\"foo\") reported as already seen")
+	}
+	if !d.SeenOrAdd("foo") {
+		t.Fatal("second SeenOrAdd(\"foo\") should report as already seen")
+	}
+	if d.SeenOrAdd("bar") {
+		t.Fatal("SeenOrAdd(\"bar\") reported as already seen")
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() = %v, want nil", err)
+	}
+}
+
+func TestBloomFilterSeenOrAdd(t *testing.T) {
+	bf := newBloomFilter(1000, 0.001)
+
+	if bf.SeenOrAdd("foo") {
+		t.Fatal("first SeenOrAdd(\"foo\") reported as already seen")
+	}
+	if !bf.SeenOrAdd("foo") {
+		t.Fatal("second SeenOrAdd(\"foo\") should report as already seen")
+	}
+	if bf.SeenOrAdd("bar") {
+		t.Fatal("SeenOrAdd(\"bar\") reported as already seen")
+	}
+	if err := bf.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+}
+
+func TestNewDedupSetValidation(t *testing.T) {
+	rep := testReporter{}
+
+	if _, err := newDedupSet(&Config{DedupStrategy: "bloom"}, rep); err == nil {
+		t.Error("expected --dedup=bloom without --allow-fp to error")
+	}
+
+	if _, err := newDedupSet(&Config{DedupStrategy: "bloom", AllowFP: true}, rep); err != nil {
+		t.Errorf("--dedup=bloom with --allow-fp should succeed, got %v", err)
+	}
+
+	if _, err := newDedupSet(&Config{DedupStrategy: "nonsense"}, rep); err == nil {
+		t.Error("expected an unknown --dedup strategy to error")
+	}
+
+	d, err := newDedupSet(&Config{DedupStrategy: "memory"}, rep)
+	if err != nil {
+		t.Fatalf("--dedup=memory: %v", err)
+	}
+	if _, ok := d.(*memoryDedupSet); !ok {
+		t.Errorf("--dedup=memory returned %T, want *memoryDedupSet", d)
+	}
+}
+
+func TestSQLiteDedupSet(t *testing.T) {
+	d, err := newSQLiteDedupSet()
+	if err != nil {
+		t.Fatalf("newSQLiteDedupSet: %v", err)
+	}
+	defer d.Close()
+
+	if d.SeenOrAdd("foo") {
+		t.Fatal("first SeenOrAdd(\"foo\") reported as already seen")
+	}
+	if !d.SeenOrAdd("foo") {
+		t.Fatal("second SeenOrAdd(\"foo\") should report as already seen")
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+}
+
+func TestSQLiteDedupSetBatchRollover(t *testing.T) {
+	d, err := newSQLiteDedupSet()
+	if err != nil {
+		t.Fatalf("newSQLiteDedupSet: %v", err)
+	}
+	defer d.Close()
+
+	// Drive the batch counter past sqliteDedupBatchSize to exercise the
+	// commit-and-reopen path; each line is unique so every call should
+	// report "new" and Err() should stay nil throughout.
+	for i := 0; i < sqliteDedupBatchSize+10; i++ {
+		line := string(rune('a')) + string(rune(i))
+		if d.SeenOrAdd(line) {
+			t.Fatalf("line %d unexpectedly reported as already seen", i)
+		}
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("Err() after batch rollover = %v, want nil", err)
+	}
+}
+
+// TestSQLiteDedupSetNonUniqueExecError reproduces a non-constraint Exec
+// failure (here, by committing the transaction out from under the still-live
+// prepared statement) and asserts SeenOrAdd sets Err() instead of silently
+// reporting a brand-new line as a duplicate.
+func TestSQLiteDedupSetNonUniqueExecError(t *testing.T) {
+	d, err := newSQLiteDedupSet()
+	if err != nil {
+		t.Fatalf("newSQLiteDedupSet: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.tx.Commit(); err != nil {
+		t.Fatalf("tx.Commit: %v", err)
+	}
+
+	d.SeenOrAdd("brand-new-line")
+	if d.Err() == nil {
+		t.Fatal("expected Err() to be set after a non-unique Exec error, got nil")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// errLockHeld is returned by tryLockFile when another process already holds
+// the lock, as opposed to some other locking failure.
+var errLockHeld = errors.New("lock already held")
+
+// tryLockFile attempts a non-blocking exclusive advisory lock (flock(2)) on
+// file, returning errLockHeld if another process already holds it.
+func tryLockFile(file *os.File) error {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the advisory lock taken by tryLockFile.
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// errLockHeld is returned by tryLockFile when another process already holds
+// the lock, as opposed to some other locking failure.
+var errLockHeld = errors.New("lock already held")
+
+// tryLockFile attempts a non-blocking exclusive lock on file via
+// LockFileEx, returning errLockHeld if another process already holds it.
+func tryLockFile(file *os.File) error {
+	ol := new(windows.Overlapped)
+	err := windows.LockFileEx(
+		windows.Handle(file.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, ol,
+	)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the lock taken by tryLockFile.
+func unlockFile(file *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, ol)
+}
@@ -2,26 +2,61 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 // Config holds the application's configuration flags
 type Config struct {
-	QuietMode      bool
-	DryRun         bool
-	TrimWhitespace bool
-	IgnoreCase     bool
-	IgnoreBlank    bool
-	ShowCounts     bool
-	InputFilename  string
-	OutputFilename string
-	BackupSuffix   string
-	DoBackup       bool
+	QuietMode         bool
+	DryRun            bool
+	TrimWhitespace    bool
+	IgnoreCase        bool
+	IgnoreBlank       bool
+	ShowCounts        bool
+	InputFilename     string
+	OutputFilename    string
+	BackupSuffix      string
+	DoBackup          bool
+	Atomic            bool
+	JSON              bool
+	Verbose           bool
+	SeedFrom          stringSliceFlag
+	SeedFromGlob      stringSliceFlag
+	DedupStrategy     string
+	ExpectedLines     uint64
+	FalsePositiveRate float64
+	AllowFP           bool
+	RetryLock         time.Duration
+}
+
+// stringSliceFlag collects repeated occurrences of a flag into a slice,
+// e.g. -seed-from a -seed-from b -> []string{"a", "b"}.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 // Stats holds runtime statistics
@@ -33,6 +68,412 @@ type Stats struct {
 	LinesWritten      int // Specifically to file
 }
 
+// reporter surfaces progress and final statistics. It lets main emit the
+// same events regardless of output format, instead of branching on
+// cfg.JSON at every Fprintf call site.
+type reporter interface {
+	Backup(src, dest string)
+	Warning(format string, args ...interface{})
+	Line(status, line string)
+	Summary(stats Stats, cfg *Config, target string)
+}
+
+// textReporter prints the traditional human-readable messages to stderr.
+type textReporter struct{}
+
+func (textReporter) Backup(src, dest string) {
+	fmt.Fprintf(os.Stderr, "Backed up %q to %q\n", src, dest)
+}
+
+func (textReporter) Warning(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+func (textReporter) Line(status, line string) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", status, line)
+}
+
+func (textReporter) Summary(stats Stats, cfg *Config, target string) {
+	if !cfg.ShowCounts {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "--- Statistics ---\n")
+	fmt.Fprintf(os.Stderr, "Lines read from stdin: %d\n", stats.LinesRead)
+	if cfg.IgnoreBlank {
+		fmt.Fprintf(os.Stderr, "Blank lines skipped:    %d\n", stats.BlankLinesSkipped)
+	}
+	fmt.Fprintf(os.Stderr, "Duplicate lines found: %d\n", stats.DuplicatesFound)
+	if cfg.DryRun {
+		fmt.Fprintf(os.Stderr, "New unique lines (dry run): %d\n", stats.NewLinesOutput)
+	} else {
+		fmt.Fprintf(os.Stderr, "New unique lines output: %d\n", stats.NewLinesOutput)
+		if target != "" {
+			fmt.Fprintf(os.Stderr, "Lines appended to file: %d\n", stats.LinesWritten)
+		}
+	}
+}
+
+// jsonReporter emits the same events as newline-delimited JSON on stderr.
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+func newJSONReporter() *jsonReporter {
+	return &jsonReporter{enc: json.NewEncoder(os.Stderr)}
+}
+
+func (j *jsonReporter) Backup(src, dest string) {
+	j.enc.Encode(struct {
+		Type string `json:"type"`
+		Src  string `json:"src"`
+		Dest string `json:"dest"`
+	}{"backup", src, dest})
+}
+
+func (j *jsonReporter) Warning(format string, args ...interface{}) {
+	j.enc.Encode(struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}{"warning", fmt.Sprintf(format, args...)})
+}
+
+func (j *jsonReporter) Line(status, line string) {
+	j.enc.Encode(struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+		Line   string `json:"line"`
+	}{"line", status, line})
+}
+
+func (j *jsonReporter) Summary(stats Stats, cfg *Config, target string) {
+	if !cfg.ShowCounts {
+		return
+	}
+	j.enc.Encode(struct {
+		Type         string `json:"type"`
+		LinesRead    int    `json:"lines_read"`
+		Duplicates   int    `json:"duplicates"`
+		BlankSkipped int    `json:"blank_skipped"`
+		New          int    `json:"new"`
+		Written      int    `json:"written"`
+		DryRun       bool   `json:"dry_run"`
+		Target       string `json:"target"`
+	}{
+		Type:         "summary",
+		LinesRead:    stats.LinesRead,
+		Duplicates:   stats.DuplicatesFound,
+		BlankSkipped: stats.BlankLinesSkipped,
+		New:          stats.NewLinesOutput,
+		Written:      stats.LinesWritten,
+		DryRun:       cfg.DryRun,
+		Target:       target,
+	})
+}
+
+// dedupSet tracks which normalized lines have already been seen, so the
+// memory, bloom, and sqlite backends are interchangeable in the hot loop.
+// SeenOrAdd reports whether line was already present, and marks it seen
+// either way.
+type dedupSet interface {
+	SeenOrAdd(line string) bool
+	// Err returns a non-nil error if the backend hit a problem that may have
+	// caused it to silently report every later line as a duplicate.
+	Err() error
+	Close() error
+}
+
+// newDedupSet builds the dedupSet selected by cfg.DedupStrategy.
+func newDedupSet(cfg *Config, rep reporter) (dedupSet, error) {
+	switch cfg.DedupStrategy {
+	case "", "memory":
+		return newMemoryDedupSet(), nil
+	case "bloom":
+		if !cfg.AllowFP {
+			return nil, errors.New(
+				"--dedup=bloom can silently drop unique lines on false positives; pass --allow-fp to acknowledge this",
+			)
+		}
+		bf := newBloomFilter(cfg.ExpectedLines, cfg.FalsePositiveRate)
+		rep.Warning(
+			"--dedup=bloom: %d bits, %d hash functions, theoretical false-positive rate %.5f",
+			bf.m, bf.k, cfg.FalsePositiveRate,
+		)
+		return bf, nil
+	case "sqlite":
+		return newSQLiteDedupSet()
+	default:
+		return nil, fmt.Errorf("unknown --dedup strategy %q (want memory, bloom, or sqlite)", cfg.DedupStrategy)
+	}
+}
+
+// memoryDedupSet is the original in-RAM map[string]bool implementation.
+type memoryDedupSet struct {
+	seen map[string]bool
+}
+
+func newMemoryDedupSet() *memoryDedupSet {
+	return &memoryDedupSet{seen: make(map[string]bool)}
+}
+
+func (m *memoryDedupSet) SeenOrAdd(line string) bool {
+	if m.seen[line] {
+		return true
+	}
+	m.seen[line] = true
+	return false
+}
+
+func (m *memoryDedupSet) Err() error { return nil }
+
+func (m *memoryDedupSet) Close() error { return nil }
+
+// bloomFilter is a standard partitioned Bloom filter sized from the
+// expected line count and target false-positive rate, with per-line bit
+// positions derived from two independent xxhash passes combined via the
+// Kirsch-Mitzenmacher trick (h_i = h1 + i*h2 mod m). Because it never
+// stores the actual lines, a false positive silently treats a genuinely
+// new line as a duplicate.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(expectedLines uint64, falsePositiveRate float64) *bloomFilter {
+	n := float64(expectedLines)
+	if n < 1 {
+		n = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	m := uint64(math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if m < 1 {
+		m = 1
+	}
+	k := uint64(math.Round((float64(m) / n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+func (b *bloomFilter) positions(line string) (h1, h2 uint64) {
+	h1 = xxHash64([]byte(line), 0)
+	h2 = xxHash64([]byte(line), h1)
+	return h1, h2
+}
+
+func (b *bloomFilter) SeenOrAdd(line string) bool {
+	h1, h2 := b.positions(line)
+
+	seen := true
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		word, bit := pos/64, pos%64
+		if b.bits[word]&(1<<bit) == 0 {
+			seen = false
+		}
+	}
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		word, bit := pos/64, pos%64
+		b.bits[word] |= 1 << bit
+	}
+	return seen
+}
+
+func (b *bloomFilter) Err() error { return nil }
+
+func (b *bloomFilter) Close() error { return nil }
+
+// xxHash64 is a self-contained implementation of the XXH64 algorithm
+// (avoids pulling in an external hashing dependency for a single call site).
+func xxHash64(input []byte, seed uint64) uint64 {
+	const (
+		prime1 = 11400714785074694791
+		prime2 = 14029467366897019727
+		prime3 = 1609587929392839161
+		prime4 = 9650029242287828579
+		prime5 = 2870177450012600261
+	)
+
+	round := func(acc, in uint64) uint64 {
+		acc += in * prime2
+		acc = (acc << 31) | (acc >> 33)
+		acc *= prime1
+		return acc
+	}
+
+	n := len(input)
+	var h64 uint64
+
+	if n >= 32 {
+		v1 := seed + prime1 + prime2
+		v2 := seed + prime2
+		v3 := seed
+		v4 := seed - prime1
+		for len(input) >= 32 {
+			v1 = round(v1, binary.LittleEndian.Uint64(input[0:8]))
+			v2 = round(v2, binary.LittleEndian.Uint64(input[8:16]))
+			v3 = round(v3, binary.LittleEndian.Uint64(input[16:24]))
+			v4 = round(v4, binary.LittleEndian.Uint64(input[24:32]))
+			input = input[32:]
+		}
+		rotl := func(x uint64, r uint) uint64 { return (x << r) | (x >> (64 - r)) }
+		h64 = rotl(v1, 1) + rotl(v2, 7) + rotl(v3, 12) + rotl(v4, 18)
+		for _, v := range []uint64{v1, v2, v3, v4} {
+			v = round(0, v)
+			h64 ^= v
+			h64 = h64*prime1 + prime4
+		}
+	} else {
+		h64 = seed + prime5
+	}
+
+	h64 += uint64(n)
+
+	for len(input) >= 8 {
+		k1 := round(0, binary.LittleEndian.Uint64(input[0:8]))
+		h64 ^= k1
+		h64 = ((h64 << 27) | (h64 >> 37)) * prime1
+		h64 += prime4
+		input = input[8:]
+	}
+	if len(input) >= 4 {
+		h64 ^= uint64(binary.LittleEndian.Uint32(input[0:4])) * prime1
+		h64 = ((h64 << 23) | (h64 >> 41)) * prime2
+		h64 += prime3
+		input = input[4:]
+	}
+	for len(input) > 0 {
+		h64 ^= uint64(input[0]) * prime5
+		h64 = ((h64 << 11) | (h64 >> 53)) * prime1
+		input = input[1:]
+	}
+
+	h64 ^= h64 >> 33
+	h64 *= prime2
+	h64 ^= h64 >> 29
+	h64 *= prime3
+	h64 ^= h64 >> 32
+	return h64
+}
+
+// sqliteDedupSet backs the dedup set with a temp SQLite database and a
+// UNIQUE index on the normalized line, so memory use stays flat regardless
+// of corpus size. Inserts are batched into 10k-row transactions.
+type sqliteDedupSet struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	insert  *sql.Stmt
+	path    string
+	pending int
+	// failure is set once a batch rollover fails, so every later call can
+	// report the error instead of mistaking a closed statement's errors for
+	// "line already seen".
+	failure error
+}
+
+const sqliteDedupBatchSize = 10000
+
+func newSQLiteDedupSet() (*sqliteDedupSet, error) {
+	tmpFile, err := ioutil.TempFile("", "onew-dedup-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp sqlite dedup db: %w", err)
+	}
+	path := tmpFile.Name()
+	tmpFile.Close()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to open sqlite dedup db %q: %w", path, err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE seen (line TEXT NOT NULL UNIQUE)`); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to create sqlite dedup table: %w", err)
+	}
+
+	s := &sqliteDedupSet{db: db, path: path}
+	if err := s.beginTx(); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteDedupSet) beginTx() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin sqlite dedup transaction: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO seen (line) VALUES (?)`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare sqlite dedup insert: %w", err)
+	}
+	s.tx = tx
+	s.insert = stmt
+	s.pending = 0
+	return nil
+}
+
+// isSQLiteUniqueViolation reports whether err is a UNIQUE constraint
+// violation from the sqlite driver, as opposed to some other failure
+// (closed statement, disk error, etc.) that happens to reach Exec.
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func (s *sqliteDedupSet) SeenOrAdd(line string) bool {
+	if s.failure != nil {
+		return true
+	}
+	_, err := s.insert.Exec(line)
+	if err != nil {
+		// A UNIQUE constraint violation means the line was already seen;
+		// any other error means we don't actually know, so record it via
+		// s.failure instead of silently treating the line as a duplicate.
+		if !isSQLiteUniqueViolation(err) {
+			s.failure = fmt.Errorf("failed to insert into sqlite dedup table: %w", err)
+		}
+		return true
+	}
+	s.pending++
+	if s.pending >= sqliteDedupBatchSize {
+		s.insert.Close()
+		if err := s.tx.Commit(); err != nil {
+			s.failure = fmt.Errorf("failed to commit sqlite dedup batch: %w", err)
+			return false
+		}
+		if err := s.beginTx(); err != nil {
+			s.failure = fmt.Errorf("failed to start next sqlite dedup batch: %w", err)
+		}
+	}
+	return false
+}
+
+func (s *sqliteDedupSet) Err() error { return s.failure }
+
+func (s *sqliteDedupSet) Close() error {
+	if s.insert != nil {
+		s.insert.Close()
+	}
+	if s.tx != nil {
+		s.tx.Commit()
+	}
+	err := s.db.Close()
+	os.Remove(s.path)
+	return err
+}
+
 // normalizeLine applies configured normalization (trimming, case)
 func normalizeLine(line string, cfg *Config) string {
 	if cfg.TrimWhitespace {
@@ -44,8 +485,84 @@ func normalizeLine(line string, cfg *Config) string {
 	return line
 }
 
+// addSeedLine normalizes line and merges it into dedup, respecting
+// cfg.IgnoreBlank the same way the InputFilename scan does.
+func addSeedLine(line string, cfg *Config, dedup dedupSet) {
+	normalized := normalizeLine(line, cfg)
+	if normalized != "" || !cfg.IgnoreBlank {
+		dedup.SeenOrAdd(normalized)
+	}
+}
+
+// scanSeedFile reads path line by line and merges each into dedup.
+// When skipCommentsAndBlank is true, blank lines and lines starting with
+// "#" (after trimming) are skipped instead of being treated as seed data.
+func scanSeedFile(path string, cfg *Config, dedup dedupSet, skipCommentsAndBlank bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open seed file %q: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if skipCommentsAndBlank {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+		}
+		addSeedLine(line, cfg, dedup)
+		if err := dedup.Err(); err != nil {
+			return fmt.Errorf("seed file %q: %w", path, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read seed file %q: %w", path, err)
+	}
+	return nil
+}
+
+// loadSeedFromFiles merges each listed file's lines verbatim (no comment or
+// blank-line filtering) into dedup. This is the --seed-from flag.
+func loadSeedFromFiles(paths []string, cfg *Config, dedup dedupSet) error {
+	for _, path := range paths {
+		if err := scanSeedFile(path, cfg, dedup, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSeedFromGlobs expands each pattern and merges the lines of every
+// matched file into dedup, skipping blank lines and #-comments. This is
+// the --seed-from-glob flag. "-" is rejected because stdin is already
+// consumed as the input stream.
+func loadSeedFromGlobs(patterns []string, cfg *Config, dedup dedupSet, rep reporter) error {
+	for _, pattern := range patterns {
+		if pattern == "-" {
+			return errors.New(`"-" is not supported for --seed-from-glob: stdin is already the input stream`)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			rep.Warning("--seed-from-glob pattern %q matched no files", pattern)
+			continue
+		}
+		for _, match := range matches {
+			if err := scanSeedFile(match, cfg, dedup, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // backupFile creates a backup of the source file if needed
-func backupFile(filename, suffix string) error {
+func backupFile(filename, suffix string, rep reporter) error {
 	if _, err := os.Stat(filename); err != nil {
 		// If file doesn't exist, no need to backup
 		if errors.Is(err, os.ErrNotExist) {
@@ -89,7 +606,164 @@ func backupFile(filename, suffix string) error {
 			err,
 		)
 	}
-	fmt.Fprintf(os.Stderr, "Backed up %q to %q\n", filename, backupName)
+	rep.Backup(filename, backupName)
+	return nil
+}
+
+const lockInitialBackoff = 1 * time.Second
+const lockMaxBackoff = 1 * time.Minute
+
+// fileLock holds an advisory exclusive lock on a file (flock(2) on Unix,
+// LockFileEx on Windows; see lock_unix.go / lock_windows.go).
+type fileLock struct {
+	file *os.File
+	// created is true if acquireLock had to create path because it didn't
+	// already exist, so callers can remove it again on an abort path that
+	// never decides to write, instead of leaving an empty file behind.
+	created bool
+}
+
+// acquireLock opens (creating if necessary) path and takes an advisory
+// exclusive lock on it, so two onew invocations writing to the same file
+// don't interleave lines or corrupt dedup semantics. If the lock is
+// already held, it retries with exponential backoff (starting at 1s,
+// capped at 1m) until the total wait exceeds retryFor, then gives up.
+func acquireLock(path string, retryFor time.Duration, rep reporter) (*fileLock, error) {
+	_, statErr := os.Stat(path)
+	created := errors.Is(statErr, os.ErrNotExist)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q for locking: %w", path, err)
+	}
+
+	start := time.Now()
+	backoff := lockInitialBackoff
+	warned := false
+	for {
+		err := tryLockFile(file)
+		if err == nil {
+			return &fileLock{file: file, created: created}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %q: %w", path, err)
+		}
+
+		if !warned {
+			rep.Warning("%q is locked by another onew process; waiting (use --retry-lock to change how long)", path)
+			warned = true
+		}
+
+		elapsed := time.Since(start)
+		if elapsed >= retryFor {
+			file.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for lock on %q", elapsed.Round(time.Second), path)
+		}
+
+		sleepFor := backoff
+		if remaining := retryFor - elapsed; remaining < sleepFor {
+			sleepFor = remaining
+		}
+		time.Sleep(sleepFor)
+
+		backoff *= 2
+		if backoff > lockMaxBackoff {
+			backoff = lockMaxBackoff
+		}
+	}
+}
+
+// Unlock releases the advisory lock and closes the underlying file.
+func (l *fileLock) Unlock() error {
+	unlockFile(l.file)
+	return l.file.Close()
+}
+
+// abortLocked reports a fatal error and exits. If lock created targetFilename
+// (because it didn't already exist), the file is removed first, while the
+// lock is still held, so a run that aborts during validation - before ever
+// deciding to write - doesn't leave an empty file behind. os.Exit skips
+// deferred cleanup, so this must be called instead of a bare os.Exit(1) at
+// any point after the lock is taken but before the target is actually
+// written to.
+func abortLocked(lock *fileLock, targetFilename string, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	if lock != nil {
+		if lock.created {
+			os.Remove(targetFilename)
+		}
+		lock.Unlock()
+	}
+	os.Exit(1)
+}
+
+// isAtomicUnsafeTarget reports whether filename is a symlink or special file,
+// for which rename-over-target semantics could silently change what the
+// path points at. A non-existent path is safe (it will simply be created).
+func isAtomicUnsafeTarget(filename string) bool {
+	info, err := os.Lstat(filename)
+	if err != nil {
+		return false
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return true
+	}
+	return !info.Mode().IsRegular()
+}
+
+// writeAtomicFile writes originalContent followed by newContent to a temp
+// file in the same directory as targetFilename, syncs it to disk, and
+// renames it over targetFilename. This ensures targetFilename either keeps
+// its old contents or has the full new contents, never a partial write.
+func writeAtomicFile(targetFilename string, originalContent, newContent []byte, rep reporter) error {
+	dir := filepath.Dir(targetFilename)
+	base := filepath.Base(targetFilename)
+
+	tmpFile, err := ioutil.TempFile(dir, base+".onew-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file in %q: %w", dir, err)
+	}
+	tmpName := tmpFile.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmpFile.Write(originalContent); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write existing content to temp file %q: %w", tmpName, err)
+	}
+	if _, err := tmpFile.Write(newContent); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write new content to temp file %q: %w", tmpName, err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync temp file %q: %w", tmpName, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file %q: %w", tmpName, err)
+	}
+
+	// Preserve the target's mode/ownership, if it already existed.
+	if info, err := os.Stat(targetFilename); err == nil {
+		if err := os.Chmod(tmpName, info.Mode()); err != nil {
+			return fmt.Errorf("failed to chmod temp file %q: %w", tmpName, err)
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := os.Chown(tmpName, int(stat.Uid), int(stat.Gid)); err != nil {
+				rep.Warning("could not preserve ownership of %q: %v", targetFilename, err)
+			}
+		}
+	}
+
+	if err := os.Rename(tmpName, targetFilename); err != nil {
+		return fmt.Errorf("failed to rename temp file %q to %q: %w", tmpName, targetFilename, err)
+	}
+	renamed = true
 	return nil
 }
 
@@ -130,6 +804,64 @@ func main() {
 		"",
 		"Output file to append unique lines (default: use input file)",
 	)
+	flag.BoolVar(
+		&cfg.Atomic,
+		"atomic",
+		false,
+		"Write via temp file + fsync + rename instead of append (preserves the target file on a crash or full disk); falls back to append for symlinks/special files",
+	)
+	flag.BoolVar(
+		&cfg.JSON,
+		"json",
+		false,
+		"Emit progress and final statistics as newline-delimited JSON on stderr instead of human-readable text",
+	)
+	flag.BoolVar(
+		&cfg.Verbose,
+		"v",
+		false,
+		"Report the status of each input line (new, duplicate, blank) as it's processed",
+	)
+	flag.Var(
+		&cfg.SeedFrom,
+		"seed-from",
+		"Merge lines from FILE into the uniqueness corpus verbatim, before reading stdin (repeatable)",
+	)
+	flag.Var(
+		&cfg.SeedFromGlob,
+		"seed-from-glob",
+		"Expand PATTERN and merge each matched file's lines into the uniqueness corpus, skipping blank lines and #-comments (repeatable)",
+	)
+	flag.StringVar(
+		&cfg.DedupStrategy,
+		"dedup",
+		"memory",
+		"Dedup backend for tracking seen lines: memory, bloom, or sqlite",
+	)
+	flag.Uint64Var(
+		&cfg.ExpectedLines,
+		"expected-lines",
+		1_000_000,
+		"Expected number of unique lines; sizes the --dedup=bloom filter",
+	)
+	flag.Float64Var(
+		&cfg.FalsePositiveRate,
+		"false-positive-rate",
+		0.001,
+		"Target false-positive rate for --dedup=bloom",
+	)
+	flag.BoolVar(
+		&cfg.AllowFP,
+		"allow-fp",
+		false,
+		"Acknowledge that --dedup=bloom can silently drop unique lines on false positives",
+	)
+	flag.DurationVar(
+		&cfg.RetryLock,
+		"retry-lock",
+		0,
+		"If the target file is locked by another onew process, retry with exponential backoff (capped at 1m) for up to this long before giving up (e.g. 30s, 5m)",
+	)
 	// Backup flag needs custom handling because of optional value
 	backupFlag := flag.String(
 		"backup",
@@ -183,31 +915,62 @@ func main() {
 		targetFilename = cfg.InputFilename // Default to writing back to input file
 	}
 
+	var rep reporter
+	if cfg.JSON {
+		rep = newJSONReporter()
+	} else {
+		rep = textReporter{}
+	}
+
+	// --- Lock Target File ---
+	// Taken before the backup and the "read existing lines" step so two
+	// onew processes writing to the same file can't race: whichever
+	// acquires the lock first finishes its backup, read, and write before
+	// the other even looks at the file.
+	var lock *fileLock
+	if !cfg.DryRun && targetFilename != "" {
+		l, err := acquireLock(targetFilename, cfg.RetryLock, rep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		lock = l
+		defer func() {
+			if err := lock.Unlock(); err != nil {
+				rep.Warning("failed to release lock on %q: %v", targetFilename, err)
+			}
+		}()
+	}
+
 	// --- Handle Backup ---
 	// Backup the input file *only* if we intend to write back to it and backup is requested.
 	if cfg.DoBackup && cfg.InputFilename != "" &&
 		targetFilename == cfg.InputFilename {
-		if err := backupFile(cfg.InputFilename, cfg.BackupSuffix); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating backup: %v\n", err)
-			os.Exit(1)
+		if err := backupFile(cfg.InputFilename, cfg.BackupSuffix, rep); err != nil {
+			abortLocked(lock, targetFilename, "Error creating backup: %v\n", err)
 		}
 	}
 
+	// --- Set Up Dedup Backend ---
+	dedup, err := newDedupSet(&cfg, rep)
+	if err != nil {
+		abortLocked(lock, targetFilename, "Error: %v\n", err)
+	}
+	defer func() {
+		if err := dedup.Close(); err != nil {
+			rep.Warning("failed to close dedup backend: %v", err)
+		}
+	}()
+
 	// --- Read Existing Lines (from InputFilename) ---
-	existingLines := make(map[string]bool)
 	if cfg.InputFilename != "" {
 		file, err := os.Open(cfg.InputFilename)
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
 				// Report errors other than "file not found"
-				fmt.Fprintf(
-					os.Stderr,
-					"Warning: could not open input file %q for reading: %v\n",
-					cfg.InputFilename,
-					err,
-				)
+				rep.Warning("could not open input file %q for reading: %v", cfg.InputFilename, err)
 			}
-			// Continue, existingLines will be empty
+			// Continue, dedup will be empty
 		} else {
 			defer file.Close()
 			scanner := bufio.NewScanner(file)
@@ -216,7 +979,7 @@ func main() {
 				// Don't add empty normalized lines to the existing set if IgnoreBlank is true,
 				// otherwise blank lines in the file would prevent adding blank lines from stdin.
 				if normalized != "" || !cfg.IgnoreBlank {
-					existingLines[normalized] = true
+					dedup.SeenOrAdd(normalized)
 				}
 			}
 			if err := scanner.Err(); err != nil {
@@ -226,32 +989,67 @@ func main() {
 			}
 		}
 	}
+	if err := dedup.Err(); err != nil {
+		abortLocked(lock, targetFilename, "Error: %v\n", err)
+	}
+
+	// --- Merge Additional Seed Sources ---
+	if len(cfg.SeedFrom) > 0 {
+		if err := loadSeedFromFiles(cfg.SeedFrom, &cfg, dedup); err != nil {
+			abortLocked(lock, targetFilename, "Error: %v\n", err)
+		}
+	}
+	if len(cfg.SeedFromGlob) > 0 {
+		if err := loadSeedFromGlobs(cfg.SeedFromGlob, &cfg, dedup, rep); err != nil {
+			abortLocked(lock, targetFilename, "Error: %v\n", err)
+		}
+	}
 
 	// --- Setup Output Writer ---
 	var outputFile *os.File
 	var outputWriter *bufio.Writer
-	var err error
+	var atomicBuf *bytes.Buffer
+	var atomicOriginal []byte
+	useAtomic := false
 
 	// Only open for writing if not dryRun AND a target file is specified
 	if !cfg.DryRun && targetFilename != "" {
-		// Use os.O_CREATE so it works even if -o specifies a new file
-		outputFile, err = os.OpenFile(
-			targetFilename,
-			os.O_APPEND|os.O_WRONLY|os.O_CREATE,
-			0644,
-		)
-		if err != nil {
-			fmt.Fprintf(
-				os.Stderr,
-				"Error: failed to open output file %q for writing: %v\n",
+		if cfg.Atomic {
+			if isAtomicUnsafeTarget(targetFilename) {
+				rep.Warning("%q is a symlink or special file; falling back to append mode", targetFilename)
+			} else {
+				useAtomic = true
+			}
+		}
+
+		if useAtomic {
+			atomicOriginal, err = ioutil.ReadFile(targetFilename)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				abortLocked(
+					lock, targetFilename,
+					"Error: failed to read target file %q for atomic write: %v\n",
+					targetFilename, err,
+				)
+			}
+			atomicBuf = &bytes.Buffer{}
+		} else {
+			// Use os.O_CREATE so it works even if -o specifies a new file
+			outputFile, err = os.OpenFile(
 				targetFilename,
-				err,
+				os.O_APPEND|os.O_WRONLY|os.O_CREATE,
+				0644,
 			)
-			os.Exit(1)
+			if err != nil {
+				abortLocked(
+					lock, targetFilename,
+					"Error: failed to open output file %q for writing: %v\n",
+					targetFilename, err,
+				)
+			}
+			defer outputFile.Close()
+			outputWriter = bufio.NewWriter(outputFile)
+			defer outputWriter.Flush() // Ensure buffer is flushed on exit
 		}
-		defer outputFile.Close()
-		outputWriter = bufio.NewWriter(outputFile)
-		defer outputWriter.Flush() // Ensure buffer is flushed on exit
 	}
 
 	// --- Process Stdin ---
@@ -264,18 +1062,31 @@ func main() {
 		// Handle blank lines from stdin
 		if cfg.IgnoreBlank && normalizedLine == "" {
 			stats.BlankLinesSkipped++
+			if cfg.Verbose {
+				rep.Line("blank", originalLine)
+			}
 			continue
 		}
 
-		// Check for duplicates
-		if existingLines[normalizedLine] {
+		// Check for duplicates (also marks normalizedLine as seen, which
+		// handles duplicates within stdin itself)
+		isDuplicate := dedup.SeenOrAdd(normalizedLine)
+		if err := dedup.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if isDuplicate {
 			stats.DuplicatesFound++
+			if cfg.Verbose {
+				rep.Line("duplicate", originalLine)
+			}
 			continue // Skip duplicate
 		}
 
-		// Mark as seen (handles duplicates within stdin itself)
-		existingLines[normalizedLine] = true
 		stats.NewLinesOutput++ // Counts lines intended for output (stdout or file)
+		if cfg.Verbose {
+			rep.Line("new", originalLine)
+		}
 
 		// Output to stdout if not quiet
 		if !cfg.QuietMode {
@@ -300,6 +1111,9 @@ func main() {
 			} else {
 				stats.LinesWritten++
 			}
+		} else if atomicBuf != nil { // Implies useAtomic
+			fmt.Fprintln(atomicBuf, originalLine)
+			stats.LinesWritten++
 		}
 	}
 
@@ -308,33 +1122,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	// --- Report Counts ---
-	if cfg.ShowCounts {
-		fmt.Fprintf(os.Stderr, "--- Statistics ---\n")
-		fmt.Fprintf(os.Stderr, "Lines read from stdin: %d\n", stats.LinesRead)
-		if cfg.IgnoreBlank {
-			fmt.Fprintf(
-				os.Stderr,
-				"Blank lines skipped:    %d\n",
-				stats.BlankLinesSkipped,
-			)
-		}
-		fmt.Fprintf(
-			os.Stderr,
-			"Duplicate lines found: %d\n",
-			stats.DuplicatesFound,
-		)
-		if cfg.DryRun {
-			fmt.Fprintf(
-				os.Stderr,
-				"New unique lines (dry run): %d\n",
-				stats.NewLinesOutput,
-			)
-		} else {
-			fmt.Fprintf(os.Stderr, "New unique lines output: %d\n", stats.NewLinesOutput)
-			if targetFilename != "" {
-				fmt.Fprintf(os.Stderr, "Lines appended to file: %d\n", stats.LinesWritten)
-			}
+	// --- Commit Atomic Write ---
+	if useAtomic {
+		if err := writeAtomicFile(targetFilename, atomicOriginal, atomicBuf.Bytes(), rep); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: atomic write to %q failed: %v\n", targetFilename, err)
+			os.Exit(1)
 		}
 	}
+
+	// --- Report Counts ---
+	rep.Summary(stats, &cfg, targetFilename)
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// captureStderr redirects os.Stderr for the duration of fn and returns
+// whatever was written to it.
+func captureStderr(t *testing.T, fn func()) []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = orig }()
+
+	fn()
+
+	w.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	return buf.Bytes()
+}
+
+func TestJSONReporterSummaryRespectsShowCounts(t *testing.T) {
+	stats := Stats{LinesRead: 3, NewLinesOutput: 2}
+
+	out := captureStderr(t, func() {
+		newJSONReporter().Summary(stats, &Config{ShowCounts: false}, "out.txt")
+	})
+	if len(out) != 0 {
+		t.Errorf("Summary with ShowCounts=false wrote %q, want nothing", out)
+	}
+
+	out = captureStderr(t, func() {
+		newJSONReporter().Summary(stats, &Config{ShowCounts: true}, "out.txt")
+	})
+	if len(out) == 0 {
+		t.Fatal("Summary with ShowCounts=true wrote nothing")
+	}
+	var decoded struct {
+		Type string `json:"type"`
+		New  int    `json:"new"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("unmarshal summary line: %v (got %q)", err, out)
+	}
+	if decoded.Type != "summary" || decoded.New != 2 {
+		t.Errorf("decoded summary = %+v, want type=summary new=2", decoded)
+	}
+}
+
+func TestTextReporterSummaryRespectsShowCounts(t *testing.T) {
+	stats := Stats{LinesRead: 3, NewLinesOutput: 2}
+
+	out := captureStderr(t, func() {
+		textReporter{}.Summary(stats, &Config{ShowCounts: false}, "out.txt")
+	})
+	if len(out) != 0 {
+		t.Errorf("Summary with ShowCounts=false wrote %q, want nothing", out)
+	}
+
+	out = captureStderr(t, func() {
+		textReporter{}.Summary(stats, &Config{ShowCounts: true}, "out.txt")
+	})
+	if len(out) == 0 {
+		t.Error("Summary with ShowCounts=true wrote nothing")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSeedFromFilesMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{}
+	dedup := newMemoryDedupSet()
+
+	err := loadSeedFromFiles([]string{filepath.Join(dir, "does-not-exist.txt")}, cfg, dedup)
+	if err == nil {
+		t.Fatal("expected an error for a missing seed file, got nil")
+	}
+}
+
+func TestLoadSeedFromGlobsMixedPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "foo\nbar\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "# comment\n\nbaz\n")
+
+	cfg := &Config{}
+	dedup := newMemoryDedupSet()
+	rep := testReporter{}
+
+	patterns := []string{
+		filepath.Join(dir, "*.txt"),
+		filepath.Join(dir, "nomatch-*.txt"), // matches nothing, should just warn
+	}
+	if err := loadSeedFromGlobs(patterns, cfg, dedup, rep); err != nil {
+		t.Fatalf("loadSeedFromGlobs: %v", err)
+	}
+
+	for _, line := range []string{"foo", "bar", "baz"} {
+		if !dedup.seen[line] {
+			t.Errorf("expected %q to be seeded, seen=%v", line, dedup.seen)
+		}
+	}
+	if dedup.seen["# comment"] || dedup.seen[""] {
+		t.Errorf("comments and blank lines should have been skipped, seen=%v", dedup.seen)
+	}
+}
+
+func TestLoadSeedFromGlobsRejectsStdin(t *testing.T) {
+	cfg := &Config{}
+	dedup := newMemoryDedupSet()
+	rep := testReporter{}
+
+	err := loadSeedFromGlobs([]string{"-"}, cfg, dedup, rep)
+	if err == nil {
+		t.Fatal("expected an error for \"-\" in --seed-from-glob, got nil")
+	}
+}
+
+func TestLoadSeedFromFilesNormalization(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "seed.txt"), "  Foo  \nBAR\n")
+
+	cfg := &Config{TrimWhitespace: true, IgnoreCase: true}
+	dedup := newMemoryDedupSet()
+
+	if err := loadSeedFromFiles([]string{filepath.Join(dir, "seed.txt")}, cfg, dedup); err != nil {
+		t.Fatalf("loadSeedFromFiles: %v", err)
+	}
+
+	if !dedup.seen["foo"] || !dedup.seen["bar"] {
+		t.Errorf("expected trimmed, lower-cased lines to be seeded, seen=%v", dedup.seen)
+	}
+	if dedup.seen["  Foo  "] || dedup.seen["BAR"] {
+		t.Errorf("raw unnormalized lines should not be present, seen=%v", dedup.seen)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writeFile(%q): %v", path, err)
+	}
+}